@@ -0,0 +1,303 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+)
+
+// arcEntry is the value held by a list.Element in the T1/T2 lists of an
+// ARCCache. The ghost lists B1/B2 only need to remember the key of an
+// evicted element, so they hold a bare ElementID instead.
+type arcEntry struct {
+	id ElementID
+	el Element
+}
+
+// cache with an Adaptive Replacement Cache (ARC) eviction policy.
+//
+// ARC keeps two lists of cached elements: T1 for elements seen once
+// ("recent") and T2 for elements seen more than once ("frequent"), plus
+// two ghost lists, B1 and B2, which remember only the keys of elements
+// recently evicted from T1 and T2 respectively. A target size p for T1
+// is adapted on every ghost-list hit, so the cache learns at runtime
+// whether the workload favors recency or frequency, which makes it
+// resistant to the cache thrashing a plain LRU suffers under scans.
+//
+// See Megiddo & Modha, "ARC: A Self-Tuning, Low Overhead Replacement
+// Cache", FAST '03.
+type ARCCache struct {
+	mutex sync.Mutex
+
+	c int // target capacity
+	p int // target size of t1, adapted on ghost hits
+
+	t1, t2, b1, b2     *list.List
+	t1m, t2m, b1m, b2m map[ElementID]*list.Element
+
+	onEvict func(Element)
+}
+
+// create a new ARC cache with room for 'size' elements
+func NewARCCache(size int) *ARCCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &ARCCache{
+		c:   size,
+		t1:  list.New(),
+		t2:  list.New(),
+		b1:  list.New(),
+		b2:  list.New(),
+		t1m: make(map[ElementID]*list.Element),
+		t2m: make(map[ElementID]*list.Element),
+		b1m: make(map[ElementID]*list.Element),
+		b2m: make(map[ElementID]*list.Element),
+	}
+}
+
+// evict the LRU entry of src, optionally pushing its key to the MRU of
+// ghost, and report it to onEvict. ghost may be nil, in which case the
+// entry is dropped from the cache entirely instead of being remembered.
+func (a *ARCCache) evictFrom(src *list.List, srcm map[ElementID]*list.Element, ghost *list.List, ghostm map[ElementID]*list.Element) {
+	back := src.Back()
+	if back == nil {
+		return
+	}
+
+	entry := back.Value.(arcEntry)
+	src.Remove(back)
+	delete(srcm, entry.id)
+
+	if ghost != nil {
+		ghostm[entry.id] = ghost.PushFront(entry.id)
+	}
+
+	if a.onEvict != nil {
+		a.onEvict(entry.el)
+	}
+}
+
+// drop the LRU key of a ghost list without touching onEvict; used to
+// keep |T1|+|B1| <= c and |T1|+|T2|+|B1|+|B2| <= 2c
+func (a *ARCCache) evictGhost(ghost *list.List, ghostm map[ElementID]*list.Element) {
+	back := ghost.Back()
+	if back == nil {
+		return
+	}
+
+	delete(ghostm, back.Value.(ElementID))
+	ghost.Remove(back)
+}
+
+// REPLACE(): move one element from T1 or T2 to its corresponding ghost
+// list to make room for an incoming one. inB2 reports whether the
+// element that triggered the replacement was found in B2.
+func (a *ARCCache) replace(inB2 bool) {
+	t1Len := a.t1.Len()
+	if t1Len > 0 && (t1Len > a.p || (t1Len == a.p && inB2)) {
+		a.evictFrom(a.t1, a.t1m, a.b1, a.b1m)
+	} else {
+		a.evictFrom(a.t2, a.t2m, a.b2, a.b2m)
+	}
+}
+
+// update (insert, promote) an element in the cache
+func (a *ARCCache) Add(el Element) {
+	if a == nil || el == nil {
+		return
+	}
+
+	id := el.ID()
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if le, ok := a.t1m[id]; ok {
+		// case I: hit in T1, promote recent -> frequent
+		a.t1.Remove(le)
+		delete(a.t1m, id)
+		a.t2m[id] = a.t2.PushFront(arcEntry{id, el})
+		return
+	}
+
+	if le, ok := a.t2m[id]; ok {
+		// hit in T2, refresh recency and value
+		le.Value = arcEntry{id, el}
+		a.t2.MoveToFront(le)
+		return
+	}
+
+	if le, ok := a.b1m[id]; ok {
+		// case II: hit in B1, grow p to favor recency
+		delta := 1
+		if a.b2.Len() > a.b1.Len() {
+			delta = a.b2.Len() / a.b1.Len()
+		}
+		a.p += delta
+		if a.p > a.c {
+			a.p = a.c
+		}
+
+		a.b1.Remove(le)
+		delete(a.b1m, id)
+		a.replace(false)
+		a.t2m[id] = a.t2.PushFront(arcEntry{id, el})
+		return
+	}
+
+	if le, ok := a.b2m[id]; ok {
+		// case III: hit in B2, shrink p to favor frequency
+		delta := 1
+		if a.b1.Len() > a.b2.Len() {
+			delta = a.b1.Len() / a.b2.Len()
+		}
+		a.p -= delta
+		if a.p < 0 {
+			a.p = 0
+		}
+
+		a.b2.Remove(le)
+		delete(a.b2m, id)
+		a.replace(true)
+		a.t2m[id] = a.t2.PushFront(arcEntry{id, el})
+		return
+	}
+
+	// case IV: full miss
+	switch {
+	case a.t1.Len()+a.b1.Len() == a.c:
+		if a.t1.Len() < a.c {
+			a.evictGhost(a.b1, a.b1m)
+			a.replace(false)
+		} else {
+			a.evictFrom(a.t1, a.t1m, nil, nil)
+		}
+	case a.t1.Len()+a.b1.Len() < a.c && a.t1.Len()+a.t2.Len()+a.b1.Len()+a.b2.Len() >= a.c:
+		if a.t1.Len()+a.t2.Len()+a.b1.Len()+a.b2.Len() == 2*a.c {
+			a.evictGhost(a.b2, a.b2m)
+		}
+		a.replace(false)
+	}
+
+	a.t1m[id] = a.t1.PushFront(arcEntry{id, el})
+}
+
+// retrieve an element from the cache, or nil if the element is not in
+// cache. A hit in T1 promotes the element to T2.
+func (a *ARCCache) Get(id ElementID) Element {
+	if a == nil {
+		return nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if le, ok := a.t1m[id]; ok {
+		entry := le.Value.(arcEntry)
+		a.t1.Remove(le)
+		delete(a.t1m, id)
+		a.t2m[id] = a.t2.PushFront(entry)
+		return entry.el
+	}
+
+	if le, ok := a.t2m[id]; ok {
+		a.t2.MoveToFront(le)
+		return le.Value.(arcEntry).el
+	}
+
+	return nil
+}
+
+// remove an element from the cache, if present. Ghost entries (keys
+// only, no element) are removed too.
+func (a *ARCCache) Remove(id ElementID) {
+	if a == nil {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if le, ok := a.t1m[id]; ok {
+		a.t1.Remove(le)
+		delete(a.t1m, id)
+		return
+	}
+	if le, ok := a.t2m[id]; ok {
+		a.t2.Remove(le)
+		delete(a.t2m, id)
+		return
+	}
+	if le, ok := a.b1m[id]; ok {
+		a.b1.Remove(le)
+		delete(a.b1m, id)
+		return
+	}
+	if le, ok := a.b2m[id]; ok {
+		a.b2.Remove(le)
+		delete(a.b2m, id)
+	}
+}
+
+// number of elements currently resident in the cache (T1 + T2; the
+// ghost lists hold no element data and are not counted)
+func (a *ARCCache) Len() int {
+	if a == nil {
+		return 0
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.t1.Len() + a.t2.Len()
+}
+
+// maximum number of elements the cache may hold
+func (a *ARCCache) Capacity() int {
+	if a == nil {
+		return 0
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.c
+}
+
+// change the maximum number of elements the cache may hold, evicting
+// from T1/T2 and trimming the ghost lists so that |T1|+|B1| <= c and
+// |T1|+|T2|+|B1|+|B2| <= 2c hold for the new c
+func (a *ARCCache) SetCapacity(size int) {
+	if a == nil || size <= 0 {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.c = size
+	if a.p > size {
+		a.p = size
+	}
+
+	for a.t1.Len()+a.t2.Len() > size {
+		a.replace(false)
+	}
+	for a.t1.Len()+a.b1.Len() > size && a.b1.Len() > 0 {
+		a.evictGhost(a.b1, a.b1m)
+	}
+	for a.t1.Len()+a.t2.Len()+a.b1.Len()+a.b2.Len() > 2*size && a.b2.Len() > 0 {
+		a.evictGhost(a.b2, a.b2m)
+	}
+}
+
+// register a callback invoked with the evicted element whenever the
+// cache drops one to make room for another
+func (a *ARCCache) SetOnEvict(fn func(Element)) {
+	if a == nil {
+		return
+	}
+
+	a.mutex.Lock()
+	a.onEvict = fn
+	a.mutex.Unlock()
+}
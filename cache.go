@@ -0,0 +1,35 @@
+package store
+
+// Cacher is implemented by the eviction policies a Store can delegate
+// caching to. Add is called both to insert a freshly loaded/stored
+// element and to promote one that was already cached. Whether a Get hit
+// also counts as a use depends on the policy: LRUCache and SizedLRUCache
+// do not promote on Get, ARCCache and LFUCache do. SetOnEvict registers a callback that is invoked,
+// synchronously, whenever an element is dropped from the cache to make
+// room for another one.
+type Cacher interface {
+	// update (insert, promote) an element in the cache
+	Add(Element)
+
+	// retrieve an Element from the cache, or nil if non-existant
+	Get(id ElementID) Element
+
+	// remove an element from the cache, if present
+	Remove(id ElementID)
+
+	// number of elements currently held in the cache
+	Len() int
+
+	// maximum number of elements the cache may hold (for
+	// SizedLRUCache, the maximum resident byte total)
+	Capacity() int
+
+	// change the maximum number of elements the cache may hold (for
+	// SizedLRUCache, the maximum resident byte total), evicting
+	// elements if the cache no longer fits
+	SetCapacity(int)
+
+	// register a callback invoked with the evicted element whenever
+	// the cache drops one to make room for another
+	SetOnEvict(func(Element))
+}
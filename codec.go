@@ -0,0 +1,259 @@
+package store
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	// ErrCodecMismatch is returned by Store.Get when the magic header
+	// written at Put time does not match the codec the Store was
+	// opened with.
+	ErrCodecMismatch = errors.New("store: element file was written with a different codec")
+
+	// ErrCorrupt is returned by a CRC32Codec reader when the checksum
+	// trailer does not match the data that was actually read back.
+	ErrCorrupt = errors.New("store: element data failed its CRC32 checksum")
+)
+
+// Codec transforms the bytes Store writes to and reads from an element
+// file, e.g. to compress them or add error detection. Store.put wraps
+// the open file handle with WrapWriter before calling el.Store, and
+// Store.get wraps it with WrapReader before calling el.Load.
+//
+// ID must return a short, stable identifier for the codec; it is
+// persisted as a magic header at the start of every file so a Store
+// opened with a different codec fails with ErrCodecMismatch instead of
+// feeding garbage to el.Load.
+type Codec interface {
+	ID() string
+	WrapWriter(io.Writer) (io.WriteCloser, error)
+	WrapReader(io.Reader) (io.ReadCloser, error)
+}
+
+// writeCodecHeader/readCodecHeader frame the codec ID as a single
+// length byte followed by that many bytes of ASCII, at the very start
+// of an element file.
+func writeCodecHeader(w io.Writer, id string) error {
+	if len(id) > 255 {
+		return errors.New("store: codec id too long for header")
+	}
+
+	if _, err := w.Write([]byte{byte(len(id))}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, id)
+	return err
+}
+
+func readCodecHeader(r io.Reader) (string, error) {
+	var length [1]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return "", err
+	}
+
+	id := make([]byte, length[0])
+	if _, err := io.ReadFull(r, id); err != nil {
+		return "", err
+	}
+
+	return string(id), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// IdentityCodec passes bytes through unchanged. It is the default codec
+// a Store uses when SetCodec has not been called.
+type IdentityCodec struct{}
+
+func (IdentityCodec) ID() string { return "identity" }
+
+func (IdentityCodec) WrapWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+func (IdentityCodec) WrapReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+// GzipCodec compresses element data with gzip.
+type GzipCodec struct{}
+
+func (GzipCodec) ID() string { return "gzip" }
+
+func (GzipCodec) WrapWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (GzipCodec) WrapReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// ZstdCodec compresses element data with zstd.
+type ZstdCodec struct{}
+
+func (ZstdCodec) ID() string { return "zstd" }
+
+func (ZstdCodec) WrapWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+func (ZstdCodec) WrapReader(r io.Reader) (io.ReadCloser, error) {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return dec.IOReadCloser(), nil
+}
+
+// XzCodec compresses element data with xz.
+type XzCodec struct{}
+
+func (XzCodec) ID() string { return "xz" }
+
+func (XzCodec) WrapWriter(w io.Writer) (io.WriteCloser, error) {
+	return xz.NewWriter(w)
+}
+
+func (XzCodec) WrapReader(r io.Reader) (io.ReadCloser, error) {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(xr), nil
+}
+
+// CRC32Codec chains another Codec and appends a CRC32 trailer to the
+// bytes it writes, verifying it on read to detect file corruption that
+// would otherwise surface as a confusing error (or no error at all)
+// from the wrapped codec or el.Load.
+type CRC32Codec struct {
+	Codec Codec
+}
+
+// wrap 'inner' with CRC32 error detection
+func NewCRC32Codec(inner Codec) *CRC32Codec {
+	return &CRC32Codec{Codec: inner}
+}
+
+func (c *CRC32Codec) ID() string {
+	return "crc32+" + c.Codec.ID()
+}
+
+func (c *CRC32Codec) WrapWriter(w io.Writer) (io.WriteCloser, error) {
+	h := crc32.NewIEEE()
+	inner, err := c.Codec.WrapWriter(io.MultiWriter(w, h))
+	if err != nil {
+		return nil, err
+	}
+
+	return &crc32Writer{w: w, hash: h, inner: inner}, nil
+}
+
+func (c *CRC32Codec) WrapReader(r io.Reader) (io.ReadCloser, error) {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		return nil, errors.New("store: CRC32Codec requires a seekable reader")
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	if end-start < 4 {
+		return nil, errors.New("store: file too short for CRC32 trailer")
+	}
+
+	if _, err := rs.Seek(end-4, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var trailer [4]byte
+	if _, err := io.ReadFull(rs, trailer[:]); err != nil {
+		return nil, err
+	}
+	want := binary.BigEndian.Uint32(trailer[:])
+
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	h := crc32.NewIEEE()
+	body := io.TeeReader(io.LimitReader(rs, end-start-4), h)
+	inner, err := c.Codec.WrapReader(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &crc32Reader{inner: inner, hash: h, want: want}, nil
+}
+
+// crc32Writer hashes every byte written through it (via the
+// io.MultiWriter set up in CRC32Codec.WrapWriter) and appends the
+// resulting checksum to the underlying writer once inner is closed.
+type crc32Writer struct {
+	w     io.Writer
+	hash  hash.Hash32
+	inner io.WriteCloser
+}
+
+func (c *crc32Writer) Write(p []byte) (int, error) {
+	return c.inner.Write(p)
+}
+
+func (c *crc32Writer) Close() error {
+	if err := c.inner.Close(); err != nil {
+		return err
+	}
+
+	var trailer [4]byte
+	binary.BigEndian.PutUint32(trailer[:], c.hash.Sum32())
+	_, err := c.w.Write(trailer[:])
+	return err
+}
+
+// crc32Reader checks the hash accumulated while reading through inner
+// against the trailer CRC32Codec.WrapReader read ahead of time, once
+// inner reports EOF.
+type crc32Reader struct {
+	inner io.ReadCloser
+	hash  hash.Hash32
+	want  uint32
+	done  bool
+}
+
+func (c *crc32Reader) Read(p []byte) (int, error) {
+	n, err := c.inner.Read(p)
+	if err == io.EOF && !c.done {
+		c.done = true
+		if c.hash.Sum32() != c.want {
+			return n, ErrCorrupt
+		}
+	}
+
+	return n, err
+}
+
+func (c *crc32Reader) Close() error {
+	return c.inner.Close()
+}
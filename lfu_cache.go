@@ -0,0 +1,227 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lfuItem is a single cached element, tracked inside the items list of
+// the lfuFreqNode matching its current access count.
+type lfuItem struct {
+	id   ElementID
+	el   Element
+	freq *list.Element // the freqList node this item currently belongs to
+}
+
+// lfuFreqNode groups every item that has been accessed exactly 'count'
+// times; the freq list is kept sorted ascending so the lowest-frequency
+// node, and therefore the next eviction candidate, is always the front.
+type lfuFreqNode struct {
+	count int
+	items *list.List // list of *lfuItem
+}
+
+// cache with an O(1) LFU (Least Frequently Used) eviction policy.
+//
+// Each cached item is reachable in O(1) through the items map, and
+// lives in the items list of the lfuFreqNode matching its access count.
+// A Get or an Add hit bumps the item to the items list of the next
+// frequency node, creating one if it doesn't already exist, and removes
+// the now-empty frequency node it came from. Eviction always removes
+// the least recently touched item of the lowest-frequency node, i.e.
+// freqList.Front().items.Back().
+type LFUCache struct {
+	mutex sync.Mutex
+
+	size     int
+	freqList *list.List                  // ascending by count; Value is *lfuFreqNode
+	items    map[ElementID]*list.Element // -> item's *list.Element within its freqNode.items
+
+	onEvict func(Element)
+}
+
+// create a new LFU cache with room for 'size' elements
+func NewLFUCache(size int) *LFUCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &LFUCache{
+		size:     size,
+		freqList: list.New(),
+		items:    make(map[ElementID]*list.Element),
+	}
+}
+
+// bump moves itemElem to the items list of the next frequency node,
+// creating that node if necessary, and drops its old frequency node if
+// it is now empty.
+func (c *LFUCache) bump(itemElem *list.Element) {
+	item := itemElem.Value.(*lfuItem)
+	curFreqElem := item.freq
+	curFreq := curFreqElem.Value.(*lfuFreqNode)
+
+	nextFreqElem := curFreqElem.Next()
+	if nextFreqElem == nil || nextFreqElem.Value.(*lfuFreqNode).count != curFreq.count+1 {
+		nextFreqElem = c.freqList.InsertAfter(&lfuFreqNode{count: curFreq.count + 1, items: list.New()}, curFreqElem)
+	}
+
+	curFreq.items.Remove(itemElem)
+	nextFreq := nextFreqElem.Value.(*lfuFreqNode)
+	item.freq = nextFreqElem
+	c.items[item.id] = nextFreq.items.PushFront(item)
+
+	if curFreq.items.Len() == 0 {
+		c.freqList.Remove(curFreqElem)
+	}
+}
+
+// evict the least recently touched item of the lowest-frequency node
+func (c *LFUCache) evict() {
+	freqElem := c.freqList.Front()
+	if freqElem == nil {
+		return
+	}
+
+	freqNode := freqElem.Value.(*lfuFreqNode)
+	back := freqNode.items.Back()
+	if back == nil {
+		return
+	}
+
+	item := back.Value.(*lfuItem)
+	freqNode.items.Remove(back)
+	delete(c.items, item.id)
+	if freqNode.items.Len() == 0 {
+		c.freqList.Remove(freqElem)
+	}
+
+	if c.onEvict != nil {
+		c.onEvict(item.el)
+	}
+}
+
+// update (insert, promote) an element in the cache
+func (c *LFUCache) Add(el Element) {
+	if c == nil || el == nil {
+		return
+	}
+
+	id := el.ID()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if itemElem, exists := c.items[id]; exists {
+		itemElem.Value.(*lfuItem).el = el
+		c.bump(itemElem)
+		return
+	}
+
+	if len(c.items) >= c.size {
+		c.evict()
+	}
+
+	firstFreqElem := c.freqList.Front()
+	if firstFreqElem == nil || firstFreqElem.Value.(*lfuFreqNode).count != 1 {
+		firstFreqElem = c.freqList.PushFront(&lfuFreqNode{count: 1, items: list.New()})
+	}
+
+	freqNode := firstFreqElem.Value.(*lfuFreqNode)
+	item := &lfuItem{id: id, el: el, freq: firstFreqElem}
+	c.items[id] = freqNode.items.PushFront(item)
+}
+
+// retrieve an element from the cache, or nil if the element is not in
+// cache. A hit bumps the element's access count.
+func (c *LFUCache) Get(id ElementID) Element {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	itemElem, exists := c.items[id]
+	if !exists {
+		return nil
+	}
+
+	el := itemElem.Value.(*lfuItem).el
+	c.bump(itemElem)
+	return el
+}
+
+// remove an element from the cache, if present
+func (c *LFUCache) Remove(id ElementID) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	itemElem, exists := c.items[id]
+	if !exists {
+		return
+	}
+
+	item := itemElem.Value.(*lfuItem)
+	freqNode := item.freq.Value.(*lfuFreqNode)
+	freqNode.items.Remove(itemElem)
+	delete(c.items, id)
+	if freqNode.items.Len() == 0 {
+		c.freqList.Remove(item.freq)
+	}
+}
+
+// number of elements currently held in the cache
+func (c *LFUCache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.items)
+}
+
+// maximum number of elements the cache may hold
+func (c *LFUCache) Capacity() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.size
+}
+
+// change the maximum number of elements the cache may hold, evicting
+// the least frequently used elements if the new capacity is smaller
+// than the current length
+func (c *LFUCache) SetCapacity(size int) {
+	if c == nil || size <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.size = size
+	for len(c.items) > c.size {
+		c.evict()
+	}
+}
+
+// register a callback invoked with the evicted element whenever the
+// cache drops one to make room for another
+func (c *LFUCache) SetOnEvict(fn func(Element)) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	c.onEvict = fn
+	c.mutex.Unlock()
+}
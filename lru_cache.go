@@ -0,0 +1,174 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache with LRU eviction policy
+type LRUCache struct {
+	l       *list.List
+	m       map[ElementID]*list.Element
+	mutex   sync.Mutex
+	size    int
+	onEvict func(Element)
+}
+
+// create a new cache with room for 'size' elements
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &LRUCache{
+		l:    list.New(),
+		m:    make(map[ElementID]*list.Element),
+		size: size,
+	}
+}
+
+// update (insert, promote) an element in the cache
+func (l *LRUCache) Add(el Element) {
+	// how cache with LRU eviction works:
+	//
+	// The cache itself is a linked list and a hash table.
+	// new elements are put in the front of the list and
+	// old ones are removed from the end back of the list.
+	// The hash table is used for lookups in O(1) time
+	//
+	// If we assume a constant element size, this
+	// implementation gives us constant space over time
+	//
+	// cache algorithm:
+	//   if in cache:
+	//     promote list element to front of list
+	//   if not in cache:
+	//     if cache not full:
+	//       insert to front of list
+	//       insert front element to hash table
+	//     if cache is full:
+	//       remove last element in list from hash table
+	//       replace last element in list with new element
+	//       move last element in list to front
+	//       insert the now front element in list to hash table
+
+	if l == nil || el == nil {
+		return
+	}
+
+	id := el.ID()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	listElem, exists := l.m[id]
+	if exists {
+		l.l.MoveToFront(listElem)
+	} else {
+		if len(l.m) < l.size {
+			listElem = l.l.PushFront(el)
+			l.m[id] = listElem
+		} else {
+			listElem = l.l.Back()
+			listVal := listElem.Value.(Element)
+			delete(l.m, listVal.ID())
+			listElem.Value = el
+			l.l.MoveToFront(listElem)
+			l.m[id] = listElem
+			if l.onEvict != nil {
+				l.onEvict(listVal)
+			}
+		}
+	}
+}
+
+// remove an element from the cache, if present
+func (l *LRUCache) Remove(id ElementID) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if listElem, exists := l.m[id]; exists {
+		l.l.Remove(listElem)
+		delete(l.m, id)
+	}
+}
+
+// number of elements currently held in the cache
+func (l *LRUCache) Len() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.l.Len()
+}
+
+// maximum number of elements the cache may hold
+func (l *LRUCache) Capacity() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.size
+}
+
+// change the maximum number of elements the cache may hold, evicting
+// elements from the back of the list if the new capacity is smaller
+// than the current length
+func (l *LRUCache) SetCapacity(size int) {
+	if l == nil || size <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.size = size
+	for l.l.Len() > l.size {
+		back := l.l.Back()
+		listVal := back.Value.(Element)
+		l.l.Remove(back)
+		delete(l.m, listVal.ID())
+		if l.onEvict != nil {
+			l.onEvict(listVal)
+		}
+	}
+}
+
+// register a callback invoked with the evicted element whenever the
+// cache drops one to make room for another
+func (l *LRUCache) SetOnEvict(fn func(Element)) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	l.onEvict = fn
+	l.mutex.Unlock()
+}
+
+// retrieve an element from the cache, or nil if
+// the element is not in cache
+func (l *LRUCache) Get(id ElementID) Element {
+
+	if l == nil {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	el, exists := l.m[id]
+	if !exists {
+		return nil
+	}
+
+	return el.Value.(Element)
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Sizer is an optional interface an Element may implement to report its
+// approximate in-memory size in bytes. SizedLRUCache uses it to charge
+// cache capacity by cumulative byte size instead of element count; an
+// Element that does not implement it is charged as a single byte.
+type Sizer interface {
+	Size() int64
+}
+
+// elementSize returns el.Size() if el implements Sizer, or 1 otherwise.
+func elementSize(el Element) int64 {
+	if s, ok := el.(Sizer); ok {
+		return s.Size()
+	}
+
+	return 1
+}
+
+// sizedEntry is the value held by a list.Element in a SizedLRUCache.
+type sizedEntry struct {
+	el   Element
+	size int64
+}
+
+// cache with an LRU eviction policy charged by cumulative element byte
+// size (via Sizer) rather than element count, similar to goleveldb's
+// cache. Elements are still ordered least-to-most recently used; the
+// cache evicts from the back of the list until the resident byte total
+// is back within maxBytes.
+type SizedLRUCache struct {
+	l     *list.List
+	m     map[ElementID]*list.Element
+	mutex sync.Mutex
+
+	maxBytes int64
+	bytes    int64
+
+	onEvict func(Element)
+}
+
+// create a new cache that evicts once the cumulative Size() of its
+// elements exceeds maxBytes
+func NewSizedLRUCache(maxBytes int64) *SizedLRUCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+
+	return &SizedLRUCache{
+		l:        list.New(),
+		m:        make(map[ElementID]*list.Element),
+		maxBytes: maxBytes,
+	}
+}
+
+// update (insert, promote) an element in the cache, evicting from the
+// back of the list until the resident byte total is within maxBytes
+func (s *SizedLRUCache) Add(el Element) {
+	if s == nil || el == nil {
+		return
+	}
+
+	id := el.ID()
+	size := elementSize(el)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if listElem, exists := s.m[id]; exists {
+		old := listElem.Value.(*sizedEntry)
+		s.bytes += size - old.size
+		listElem.Value = &sizedEntry{el: el, size: size}
+		s.l.MoveToFront(listElem)
+	} else {
+		listElem = s.l.PushFront(&sizedEntry{el: el, size: size})
+		s.m[id] = listElem
+		s.bytes += size
+	}
+
+	for s.bytes > s.maxBytes && s.l.Len() > 0 {
+		s.evictBack()
+	}
+}
+
+// evictBack removes the least recently used element and reports it to
+// onEvict, if set. Callers must hold s.mutex.
+func (s *SizedLRUCache) evictBack() {
+	back := s.l.Back()
+	entry := back.Value.(*sizedEntry)
+	s.l.Remove(back)
+	delete(s.m, entry.el.ID())
+	s.bytes -= entry.size
+	if s.onEvict != nil {
+		s.onEvict(entry.el)
+	}
+}
+
+// remove an element from the cache, if present
+func (s *SizedLRUCache) Remove(id ElementID) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if listElem, exists := s.m[id]; exists {
+		entry := listElem.Value.(*sizedEntry)
+		s.l.Remove(listElem)
+		delete(s.m, id)
+		s.bytes -= entry.size
+	}
+}
+
+// number of elements currently held in the cache
+func (s *SizedLRUCache) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.l.Len()
+}
+
+// Capacity returns the cache's maximum resident byte size, truncated to
+// an int to satisfy Cacher; use ByteCapacity for the exact int64 value.
+func (s *SizedLRUCache) Capacity() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return int(s.maxBytes)
+}
+
+// SetCapacity sets the cache's maximum resident byte size, evicting
+// elements from the back of the list if the new capacity is smaller
+// than the current byte total. It is equivalent to
+// SetByteCapacity(int64(maxBytes)); use SetByteCapacity directly to set
+// a capacity beyond the range of int.
+func (s *SizedLRUCache) SetCapacity(maxBytes int) {
+	s.SetByteCapacity(int64(maxBytes))
+}
+
+// ByteCapacity returns the cache's maximum resident byte size.
+func (s *SizedLRUCache) ByteCapacity() int64 {
+	if s == nil {
+		return 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.maxBytes
+}
+
+// SetByteCapacity sets the cache's maximum resident byte size, evicting
+// elements from the back of the list if the new capacity is smaller
+// than the current byte total.
+func (s *SizedLRUCache) SetByteCapacity(maxBytes int64) {
+	if s == nil || maxBytes <= 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.maxBytes = maxBytes
+	for s.bytes > s.maxBytes && s.l.Len() > 0 {
+		s.evictBack()
+	}
+}
+
+// Bytes returns the cumulative Size() of the elements currently held in
+// the cache.
+func (s *SizedLRUCache) Bytes() int64 {
+	if s == nil {
+		return 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.bytes
+}
+
+// register a callback invoked with the evicted element whenever the
+// cache drops one to make room for another
+func (s *SizedLRUCache) SetOnEvict(fn func(Element)) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	s.onEvict = fn
+	s.mutex.Unlock()
+}
+
+// retrieve an element from the cache, or nil if the element is not in
+// cache. Unlike Add, Get does not promote the element.
+func (s *SizedLRUCache) Get(id ElementID) Element {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	listElem, exists := s.m[id]
+	if !exists {
+		return nil
+	}
+
+	return listElem.Value.(*sizedEntry).el
+}
@@ -1,9 +1,11 @@
-//
 // concurrency-safe file-backed element store
 //
-//   - Asynchronous writes (errors reported on next action)
-//   - Optional cache with LRU eviction on either
-//     store insertion or store retrieval
+//   - Asynchronous writes: Put returns before the write completes. Pass
+//     an onDone callback to learn the outcome of that specific write, or
+//     call Sync, which blocks until every Put since the last Sync has
+//     finished and returns their errors joined together.
+//   - Optional cache with pluggable eviction (LRU, ARC, LFU, byte-charge)
+//     on either store insertion or store retrieval
 //
 // To avoid a lot of casting from store.Element on Store#Get, you should probably
 // write a wrapper around this element store for each type you intend
@@ -13,7 +15,6 @@
 package store
 
 import (
-	"container/list"
 	"errors"
 	"io"
 	"os"
@@ -57,106 +58,6 @@ type Element interface {
 	ID() ElementID
 }
 
-type Cache interface {
-	// update (insert, promote) an element in the cache
-	Cache(Element)
-
-	// retrieve an Element from the cache, or nil if non-existant
-	Get(id ElementID) Element
-}
-
-// cache with LRU eviction policy
-type LRUCache struct {
-	l     *list.List
-	m     map[ElementID]*list.Element
-	mutex sync.Mutex
-	size  int
-}
-
-// create a new cache with room for 'size' elements
-func NewLRUCache(size int) *LRUCache {
-	if size <= 0 {
-		return nil
-	}
-
-	return &LRUCache{
-		l:    list.New(),
-		m:    make(map[ElementID]*list.Element),
-		size: size,
-	}
-}
-
-// update (insert, promote) an element in the cache
-func (l *LRUCache) Cache(el Element) {
-	// how cache with LRU eviction works:
-	//
-	// The cache itself is a linked list and a hash table.
-	// new elements are put in the front of the list and
-	// old ones are removed from the end back of the list.
-	// The hash table is used for lookups in O(1) time
-	//
-	// If we assume a constant element size, this
-	// implementation gives us constant space over time
-	//
-	// cache algorithm:
-	//   if in cache:
-	//     promote list element to front of list
-	//   if not in cache:
-	//     if cache not full:
-	//       insert to front of list
-	//       insert front element to hash table
-	//     if cache is full:
-	//       remove last element in list from hash table
-	//       replace last element in list with new element
-	//       move last element in list to front
-	//       insert the now front element in list to hash table
-
-	if l == nil || el == nil {
-		return
-	}
-
-	id := el.ID()
-
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-
-	listElem, exists := l.m[id]
-	if exists {
-		l.l.MoveToFront(listElem)
-	} else {
-		if len(l.m) < l.size {
-			listElem = l.l.PushFront(el)
-			l.m[id] = listElem
-		} else {
-			listElem = l.l.Back()
-			listVal := listElem.Value.(Element)
-			delete(l.m, listVal.ID())
-			listElem.Value = el
-			l.l.MoveToFront(listElem)
-			l.m[id] = listElem
-		}
-	}
-}
-
-// retrieve an element from the cache, or nil if
-// the element is not in cache
-func (l *LRUCache) Get(id ElementID) Element {
-
-	if l == nil {
-		return nil
-	}
-
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-
-	el, exists := l.m[id]
-	if !exists {
-		return nil
-	}
-
-	return el.Value.(Element)
-}
-
 // Tells the store when to pass an element
 // to the cache
 type CacheMode int
@@ -169,24 +70,41 @@ const (
 type Store struct {
 	path string
 
-	cache     Cache
+	cache     Cacher
 	cacheMode CacheMode
 
+	codec Codec
+
 	inventory map[ElementID]struct{}
 	ilock     sync.RWMutex
 
 	inMem     map[ElementID]Element
 	inMemLock sync.RWMutex
 
-	writeErr     error
+	inFlight     map[ElementID]*inFlightLoad
+	inFlightLock sync.Mutex
+
+	writeErrsLock sync.Mutex
+	writeErrs     []error
+
 	activeWrites sync.WaitGroup
 }
 
+// inFlightLoad is the single-flight entry shared by Get and Prefetch
+// callers racing to load the same ElementID from disk: the caller that
+// creates the entry does the actual read, everyone else waits on done.
+type inFlightLoad struct {
+	done chan struct{}
+	el   Element
+	err  error
+}
+
 func New(path string) (*Store, error) {
 	s := &Store{
 		path:      filepath.Clean(path),
 		inventory: make(map[ElementID]struct{}),
 		inMem:     make(map[ElementID]Element),
+		inFlight:  make(map[ElementID]*inFlightLoad),
 	}
 
 	os.MkdirAll(s.path, 0700)
@@ -210,11 +128,56 @@ func New(path string) (*Store, error) {
 	return s, nil
 }
 
-func (s *Store) SetCache(c Cache, mode CacheMode) {
+func (s *Store) SetCache(c Cacher, mode CacheMode) {
 	s.cache = c
 	s.cacheMode = mode
 }
 
+// byteCapacitySetter is implemented by cache policies, such as
+// SizedLRUCache, whose capacity is measured in bytes rather than
+// element count.
+type byteCapacitySetter interface {
+	SetByteCapacity(int64)
+}
+
+// SetCacheCapacity resizes the Store's current cache at runtime. For a
+// byte-charge cache such as SizedLRUCache, n is a number of bytes and is
+// applied via SetByteCapacity; for any other Cacher, n is forwarded to
+// SetCapacity as an element count. It is a no-op if no cache is set.
+func (s *Store) SetCacheCapacity(n int64) {
+	if s.cache == nil {
+		return
+	}
+
+	if bc, ok := s.cache.(byteCapacitySetter); ok {
+		bc.SetByteCapacity(n)
+		return
+	}
+
+	s.cache.SetCapacity(int(n))
+}
+
+// SetCodec installs the Codec that Store.get/Store.put wrap element
+// files with. Get returns ErrCodecMismatch for an element file whose
+// header names a different codec. A file written without any codec
+// header is not recognised as such: its first byte is read as a header
+// length, so Get fails with either ErrCodecMismatch or a read error. If
+// SetCodec is never called, Store uses IdentityCodec.
+func (s *Store) SetCodec(c Codec) {
+	s.codec = c
+}
+
+// codecOrDefault returns the Codec a Store reads and writes elements
+// with, falling back to IdentityCodec when SetCodec has not been
+// called.
+func (s *Store) codecOrDefault() Codec {
+	if s.codec != nil {
+		return s.codec
+	}
+
+	return IdentityCodec{}
+}
+
 func (s *Store) Has(id ElementID) bool {
 	// we check inMem first, because the inventory
 	// is updated before inMem is updated
@@ -254,7 +217,24 @@ func (s *Store) get(el Element) error {
 	}
 
 	defer fh.Close()
-	return el.Load(fh)
+
+	codec := s.codecOrDefault()
+	gotID, err := readCodecHeader(fh)
+	if err != nil {
+		return err
+	}
+
+	if gotID != codec.ID() {
+		return ErrCodecMismatch
+	}
+
+	r, err := codec.WrapReader(fh)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+	return el.Load(r)
 }
 
 // retrieve an Element either from cache or from disk.
@@ -262,8 +242,14 @@ func (s *Store) get(el Element) error {
 // If retrieved from disk, the Element passed to Get will be
 // loaded with the data from disk. Therefor, this function
 // should always be called like:
-//   var el = SomeElement{ID: someID}
-//   ret, err := s.Get(&el)
+//
+//	var el = SomeElement{ID: someID}
+//	ret, err := s.Get(&el)
+//
+// If a load for the same ID is already in flight, either started by a
+// concurrent Get or by Prefetch, Get attaches to it instead of opening
+// the file a second time, and returns whatever Element that load
+// produced.
 func (s *Store) Get(element Element) (Element, error) {
 	id := element.ID()
 	if s.cache != nil {
@@ -291,20 +277,88 @@ func (s *Store) Get(element Element) (Element, error) {
 		return nil, ErrDoesNotExist
 	}
 
+	return s.load(element)
+}
+
+// Prefetch begins loading the elements identified by ids from disk in
+// background goroutines, without blocking the caller. factory is called
+// once for each id that is loaded, on the calling goroutine, to produce
+// the Element that will receive the data; ids that are already cached,
+// still in the write buffer or not in the store are skipped without
+// calling it. It should behave like the element passed to Get, e.g.
+// "return &SomeElement{ID: id}".
+//
+// Prefetch shares the same single-flight path as Get, so a later Get
+// for a prefetched id attaches to the load already in flight instead of
+// opening the file again.
+func (s *Store) Prefetch(factory func(ElementID) Element, ids ...ElementID) {
+	for _, id := range ids {
+		if s.cache != nil && s.cache.Get(id) != nil {
+			continue
+		}
+
+		s.inMemLock.RLock()
+		_, exists := s.inMem[id]
+		s.inMemLock.RUnlock()
+		if exists {
+			continue
+		}
+
+		s.ilock.RLock()
+		_, exists = s.inventory[id]
+		s.ilock.RUnlock()
+		if !exists {
+			continue
+		}
+
+		go s.load(factory(id))
+	}
+}
+
+// load an Element from disk, sharing the read with any other caller
+// already loading the same ID via the inFlight single-flight map, and
+// cache the result if CacheOnGet is set.
+func (s *Store) load(element Element) (Element, error) {
+	id := element.ID()
+
+	s.inFlightLock.Lock()
+	if fl, ok := s.inFlight[id]; ok {
+		s.inFlightLock.Unlock()
+		<-fl.done
+		return fl.el, fl.err
+	}
+
+	fl := &inFlightLoad{done: make(chan struct{})}
+	s.inFlight[id] = fl
+	s.inFlightLock.Unlock()
+
 	err := s.get(element)
-	if err != nil {
-		return nil, err
+	if err == nil && s.cache != nil && (s.cacheMode&CacheOnGet != 0) {
+		s.cache.Add(element)
 	}
 
-	if s.cache != nil && (s.cacheMode&CacheOnGet != 0) {
-		s.cache.Cache(element)
+	// Record the result and remove this load from s.inFlight in the
+	// same critical section that closes fl.done, so a concurrent
+	// caller can never observe the entry gone before the result it
+	// protects is ready; otherwise it would start a redundant load of
+	// its own instead of waiting on fl.done.
+	s.inFlightLock.Lock()
+	delete(s.inFlight, id)
+	fl.err = err
+	if err == nil {
+		fl.el = element
 	}
+	close(fl.done)
+	s.inFlightLock.Unlock()
 
-	return element, nil
+	if err != nil {
+		return nil, err
+	}
 
+	return element, nil
 }
 
-func (s Store) put(el Element) error {
+func (s *Store) put(el Element) error {
 	dir := s.eldir(el)
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return err
@@ -316,19 +370,43 @@ func (s Store) put(el Element) error {
 		return err
 	}
 
-	if err := el.Store(fh); err != nil {
+	codec := s.codecOrDefault()
+	if err := writeCodecHeader(fh, codec.ID()); err != nil {
+		fh.Close()
 		return err
 	}
 
-	return fh.Close()
-}
+	w, err := codec.WrapWriter(fh)
+	if err != nil {
+		fh.Close()
+		return err
+	}
 
-func (s *Store) Put(el Element) error {
+	if err := el.Store(w); err != nil {
+		w.Close()
+		fh.Close()
+		return err
+	}
 
-	if s.writeErr != nil {
-		return s.writeErr
+	if err := w.Close(); err != nil {
+		fh.Close()
+		return err
 	}
 
+	return fh.Close()
+}
+
+// Put writes el to disk asynchronously and returns immediately; the
+// write error, if any, is not returned by Put itself. To learn the
+// outcome of this specific write, pass onDone: it is invoked exactly
+// once, with the result of the write, on the write's own goroutine.
+// Alternatively, call Sync, which blocks until every Put started since
+// the last Sync has completed and returns their errors joined together.
+//
+// If the write fails, el is rolled back out of the in-memory write
+// buffer, so it is not visible via Has or Get afterwards and a retried
+// Put for the same ID is free to proceed.
+func (s *Store) Put(el Element, onDone ...func(error)) error {
 	id := el.ID()
 	if s.Has(id) {
 		return ErrAlreadyExists
@@ -349,24 +427,41 @@ func (s *Store) Put(el Element) error {
 			s.inMemLock.Unlock()
 		}()
 
-		if err := s.put(el); err != nil {
-			s.writeErr = err
+		err := s.put(el)
+		if err != nil {
+			s.writeErrsLock.Lock()
+			s.writeErrs = append(s.writeErrs, err)
+			s.writeErrsLock.Unlock()
 		} else {
 			var x struct{}
 			s.ilock.Lock()
 			s.inventory[id] = x
 			s.ilock.Unlock()
 			if s.cache != nil && (s.cacheMode&CacheOnPut) != 0 {
-				s.cache.Cache(el)
+				s.cache.Add(el)
 			}
 		}
+
+		for _, fn := range onDone {
+			fn(err)
+		}
 	}()
 
 	return nil
 }
 
-func (s *Store) Sync() {
+// Sync blocks until every Put started since the last Sync call has
+// completed, then returns their errors joined together with
+// errors.Join, or nil if they all succeeded.
+func (s *Store) Sync() error {
 	s.activeWrites.Wait()
+
+	s.writeErrsLock.Lock()
+	errs := s.writeErrs
+	s.writeErrs = nil
+	s.writeErrsLock.Unlock()
+
+	return errors.Join(errs...)
 }
 
 func (s *Store) Remove() error {
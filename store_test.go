@@ -3,8 +3,12 @@ package store
 import (
 	"encoding/json"
 	"io"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var TestPath = "a9sfdsv2"
@@ -46,7 +50,7 @@ var TestElems = []TestElement{
 
 func TestLRUInsertion(t *testing.T) {
 	lru := NewLRUCache(1)
-	lru.Cache(&TestElems[0])
+	lru.Add(&TestElems[0])
 	retrieved := lru.Get(TestElems[0].ElementID)
 	if retrieved == nil || retrieved.(*TestElement).Data != TestElems[0].Data {
 		t.Fatal("expected ", TestElems[0].Data, " got ", retrieved.(*TestElement).Data)
@@ -55,9 +59,9 @@ func TestLRUInsertion(t *testing.T) {
 
 func TestLRUEviction(t *testing.T) {
 	lru := NewLRUCache(1)
-	lru.Cache(&TestElems[0])
-	lru.Cache(&TestElems[1])
-	lru.Cache(&TestElems[2])
+	lru.Add(&TestElems[0])
+	lru.Add(&TestElems[1])
+	lru.Add(&TestElems[2])
 
 	retrieved := lru.Get(TestElems[0].ElementID)
 	if retrieved != nil {
@@ -77,10 +81,10 @@ func TestLRUEviction(t *testing.T) {
 
 func TestLRUPromotion(t *testing.T) {
 	lru := NewLRUCache(2)
-	lru.Cache(&TestElems[0])
-	lru.Cache(&TestElems[1])
-	lru.Cache(&TestElems[0])
-	lru.Cache(&TestElems[2])
+	lru.Add(&TestElems[0])
+	lru.Add(&TestElems[1])
+	lru.Add(&TestElems[0])
+	lru.Add(&TestElems[2])
 	// lru should be (left == front) : [2, 0]
 
 	retrieved := lru.Get(TestElems[0].ElementID)
@@ -104,7 +108,7 @@ func TestNilCache(t *testing.T) {
 	// zero size cache == nil
 	// should still work
 	lru := NewLRUCache(0)
-	lru.Cache(&TestElems[0])
+	lru.Add(&TestElems[0])
 	retrieved := lru.Get(TestElems[0].ElementID)
 	if retrieved != nil {
 		t.Fatal("expected nil, got ", retrieved)
@@ -113,7 +117,7 @@ func TestNilCache(t *testing.T) {
 
 func BenchmarkLRURetrieval(b *testing.B) {
 	lru := NewLRUCache(1)
-	lru.Cache(&TestElems[0])
+	lru.Add(&TestElems[0])
 
 	for i := 0; i < b.N; i++ {
 		el := lru.Get(TestElems[0].ElementID)
@@ -123,6 +127,93 @@ func BenchmarkLRURetrieval(b *testing.B) {
 	}
 }
 
+func TestARCInsertion(t *testing.T) {
+	arc := NewARCCache(1)
+	arc.Add(&TestElems[0])
+	retrieved := arc.Get(TestElems[0].ElementID)
+	if retrieved == nil || retrieved.(*TestElement).Data != TestElems[0].Data {
+		t.Fatal("expected ", TestElems[0].Data, " got ", retrieved)
+	}
+}
+
+func TestARCEviction(t *testing.T) {
+	arc := NewARCCache(1)
+	arc.Add(&TestElems[0])
+	arc.Add(&TestElems[1])
+
+	retrieved := arc.Get(TestElems[0].ElementID)
+	if retrieved != nil {
+		t.Fatal("able to retrieve an element that should have been evicted")
+	}
+
+	retrieved = arc.Get(TestElems[1].ElementID)
+	if retrieved == nil || retrieved.(*TestElement).Data != TestElems[1].Data {
+		t.Fatal("expected ", TestElems[1].Data, " got ", retrieved)
+	}
+}
+
+func TestARCPromotion(t *testing.T) {
+	// a second Add of the same id is a hit in T1, which should promote
+	// the element to T2. At capacity 2 the promoted entry leaves room
+	// (|T1|+|T2| < c), so inserting one fresh key into T1 evicts nothing
+	// and must not touch T2.
+	arc := NewARCCache(2)
+	arc.Add(&TestElems[0])
+	arc.Add(&TestElems[0])
+	arc.Add(&TestElems[1])
+
+	retrieved := arc.Get(TestElems[0].ElementID)
+	if retrieved == nil || retrieved.(*TestElement).Data != TestElems[0].Data {
+		t.Fatal("expected promoted element to survive, got ", retrieved)
+	}
+}
+
+func TestARCGhostHitB2(t *testing.T) {
+	// promote TestElems[0] into T2, then promote TestElems[1] into T2
+	// too so a later full miss has to evict from T2, sending
+	// TestElems[0]'s key to the B2 ghost list. Re-Adding TestElems[0]
+	// should then be a B2 ghost hit, which must land it back in T2
+	// instead of being treated as an ordinary new insertion.
+	arc := NewARCCache(2)
+	arc.Add(&TestElems[0])
+	arc.Add(&TestElems[0])
+	arc.Add(&TestElems[1])
+	arc.Add(&TestElems[1])
+	arc.Add(&TestElems[2])
+
+	if retrieved := arc.Get(TestElems[0].ElementID); retrieved != nil {
+		t.Fatal("expected TestElems[0] to have been evicted to the B2 ghost list, got ", retrieved)
+	}
+
+	arc.Add(&TestElems[0])
+	retrieved := arc.Get(TestElems[0].ElementID)
+	if retrieved == nil || retrieved.(*TestElement).Data != TestElems[0].Data {
+		t.Fatal("expected B2 ghost hit to restore the element, got ", retrieved)
+	}
+}
+
+func TestARCScanResistance(t *testing.T) {
+	// a hot item that is accessed twice is promoted into T2 and must
+	// survive a long scan of once-only items that would thrash a plain
+	// LRU cache of the same size
+	const size = 4
+	arc := NewARCCache(size)
+
+	hot := TestElement{ElementID: 1000, Data: "hot"}
+	arc.Add(&hot)
+	arc.Add(&hot)
+
+	for i := ElementID(1); i <= 50; i++ {
+		scan := TestElement{ElementID: i, Data: "scan"}
+		arc.Add(&scan)
+	}
+
+	retrieved := arc.Get(hot.ElementID)
+	if retrieved == nil || retrieved.(*TestElement).Data != "hot" {
+		t.Fatal("expected scan-resistant hot item to survive, got ", retrieved)
+	}
+}
+
 func TestRetrieveNonExistant(t *testing.T) {
 	s, err := New(TestPath)
 	if err != nil {
@@ -194,6 +285,157 @@ func TestPersistency(t *testing.T) {
 	}
 }
 
+func TestCodecRoundtrip(t *testing.T) {
+	for _, codec := range []Codec{GzipCodec{}, ZstdCodec{}, XzCodec{}, NewCRC32Codec(GzipCodec{}), NewCRC32Codec(ZstdCodec{})} {
+		s, err := New(TestPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.SetCodec(codec)
+
+		elem := &TestElems[0]
+		if err := s.Put(elem); err != nil {
+			s.Remove()
+			t.Fatal(err)
+		}
+
+		s.Sync()
+		retElem := &TestElement{ElementID: elem.ElementID}
+		el, err := s.Get(retElem)
+		s.Remove()
+		if err != nil {
+			t.Fatal(codec.ID(), err)
+		}
+
+		if el == nil || el.(*TestElement).Data != elem.Data {
+			t.Fatal(codec.ID(), "unexpected value:", el)
+		}
+	}
+}
+
+func TestCodecMismatch(t *testing.T) {
+	s, err := New(TestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.SetCodec(GzipCodec{})
+
+	elem := &TestElems[0]
+	if err := s.Put(elem); err != nil {
+		s.Remove()
+		t.Fatal(err)
+	}
+
+	s.Sync()
+
+	news, err := New(TestPath)
+	if err != nil {
+		s.Remove()
+		t.Fatal(err)
+	}
+
+	news.SetCodec(XzCodec{})
+	defer news.Remove()
+
+	_, err = news.Get(&TestElement{ElementID: elem.ElementID})
+	if err != ErrCodecMismatch {
+		t.Fatal("expected ErrCodecMismatch, got", err)
+	}
+}
+
+// countingElement wraps TestElement to count how many times Load is
+// actually invoked on it, so tests can tell whether a disk read was
+// shared via single-flight or happened redundantly.
+type countingElement struct {
+	TestElement
+	loads *int32
+}
+
+func (el *countingElement) Load(r io.Reader) error {
+	atomic.AddInt32(el.loads, 1)
+	// Give the other concurrently-launched Gets time to reach the
+	// in-flight check before this load completes, so the test reliably
+	// exercises the case where they join it instead of racing ahead.
+	time.Sleep(5 * time.Millisecond)
+	return el.TestElement.Load(r)
+}
+
+func TestGetSingleFlight(t *testing.T) {
+	s, err := New(TestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Remove()
+
+	elem := &TestElems[0]
+	if err := s.Put(elem); err != nil {
+		t.Fatal(err)
+	}
+	s.Sync()
+
+	const n = 8
+	var loads int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			<-start
+			el := &countingElement{TestElement: TestElement{ElementID: elem.ElementID}, loads: &loads}
+			if _, err := s.Get(el); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&loads); got != 1 {
+		t.Fatal("expected exactly one Load across concurrent Gets, got", got)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	s, err := New(TestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Remove()
+
+	for i := range TestElems {
+		if err := s.Put(&TestElems[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Sync()
+
+	ids := make([]ElementID, len(TestElems))
+	for i, el := range TestElems {
+		ids[i] = el.ElementID
+	}
+
+	s.Prefetch(func(id ElementID) Element {
+		return &TestElement{ElementID: id}
+	}, ids...)
+
+	for i, id := range ids {
+		retElem := &TestElement{ElementID: id}
+		el, err := s.Get(retElem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if el.(*TestElement).Data != TestElems[i].Data {
+			t.Fatal("expected", TestElems[i].Data, "got", el.(*TestElement).Data)
+		}
+	}
+}
+
 func TestConcurrentDuplicateInsertion(t *testing.T) {
 	var err1, err2 error
 
@@ -227,15 +469,99 @@ func TestConcurrentDuplicateInsertion(t *testing.T) {
 	}
 }
 
-func testPut(t *testing.T, midSync bool, cacheMode CacheMode, cacheSize, nelems int) {
+// forcePutFailure pre-creates el's element file as a directory, so the
+// os.OpenFile a later Put does against that path fails regardless of
+// the user the test runs as.
+func forcePutFailure(t *testing.T, s *Store, el Element) {
+	path := filepath.Join(s.eldir(el), el.ID().String())
+	if err := os.MkdirAll(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutWriteError(t *testing.T) {
+	s, err := New(TestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Remove()
+
+	elem := &TestElement{ElementID: 42, Data: "x"}
+	forcePutFailure(t, s, elem)
+
+	var cbErr error
+	if err := s.Put(elem, func(err error) { cbErr = err }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Sync(); err == nil {
+		t.Fatal("expected Sync to report the failed write")
+	}
+
+	if cbErr == nil {
+		t.Fatal("expected onDone to be called with the write error")
+	}
+
+	if s.Has(elem.ElementID) {
+		t.Fatal("failed Put should not be visible via Has")
+	}
+
+	if _, err := s.Get(&TestElement{ElementID: elem.ElementID}); err != ErrDoesNotExist {
+		t.Fatal("expected ErrDoesNotExist, got", err)
+	}
+}
+
+func TestPutMultipleWriteErrors(t *testing.T) {
+	s, err := New(TestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Remove()
+
+	good := &TestElement{ElementID: 1, Data: "ok"}
+	bad := &TestElement{ElementID: 2, Data: "bad"}
+	forcePutFailure(t, s, bad)
+
+	var goodErr, badErr error
+	if err := s.Put(good, func(err error) { goodErr = err }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(bad, func(err error) { badErr = err }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Sync(); err == nil {
+		t.Fatal("expected Sync to report the failed write")
+	}
+
+	if goodErr != nil {
+		t.Fatal("expected good Put to succeed, got", goodErr)
+	}
+
+	if badErr == nil {
+		t.Fatal("expected bad Put to fail, got nil")
+	}
+}
+
+func newLRUCacher(size int) Cacher {
+	return NewLRUCache(size)
+}
+
+func newLFUCacher(size int) Cacher {
+	return NewLFUCache(size)
+}
+
+func testPut(t *testing.T, newCache func(int) Cacher, midSync bool, cacheMode CacheMode, cacheSize, nelems int) {
 	s, err := New(TestPath)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	if cacheSize > 0 {
-		cache := NewLRUCache(cacheSize)
-		s.SetCache(cache, cacheMode)
+		s.SetCache(newCache(cacheSize), cacheMode)
 	}
 
 	defer s.Remove()
@@ -272,60 +598,307 @@ func testPut(t *testing.T, midSync bool, cacheMode CacheMode, cacheSize, nelems
 
 func TestPut(t *testing.T) {
 	// don't sync, don't cache, one element
-	testPut(t, false, 0, 0, 1)
+	testPut(t, newLRUCacher, false, 0, 0, 1)
 }
 
 func TestPutSync(t *testing.T) {
 	// sync, don't cache, one element
-	testPut(t, true, 0, 0, 1)
+	testPut(t, newLRUCacher, true, 0, 0, 1)
 }
 
 func TestPutMultiple(t *testing.T) {
 	// don't sync, don't cache, three elements
-	testPut(t, false, 0, 0, 3)
+	testPut(t, newLRUCacher, false, 0, 0, 3)
 }
 
 func TestPutSyncMultiple(t *testing.T) {
 	// sync, don't cache, three elements
-	testPut(t, true, 0, 0, 3)
+	testPut(t, newLRUCacher, true, 0, 0, 3)
 }
 
 func TestPutSmallCache(t *testing.T) {
 	// don't sync, cache, one element
-	testPut(t, false, CacheOnGet|CacheOnPut, 1, 1)
+	testPut(t, newLRUCacher, false, CacheOnGet|CacheOnPut, 1, 1)
 }
 
 func TestPutSyncSmallCache(t *testing.T) {
 	// sync, cache, one element
-	testPut(t, true, CacheOnGet|CacheOnPut, 1, 1)
+	testPut(t, newLRUCacher, true, CacheOnGet|CacheOnPut, 1, 1)
 }
 
 func TestPutMultipleSmallCache(t *testing.T) {
 	// don't sync, cache, three elements
-	testPut(t, false, CacheOnGet|CacheOnPut, 1, 3)
+	testPut(t, newLRUCacher, false, CacheOnGet|CacheOnPut, 1, 3)
 }
 
 func TestPutSyncMultipleSmallCache(t *testing.T) {
 	// sync, cache, three elements
-	testPut(t, true, CacheOnGet|CacheOnPut, 1, 3)
+	testPut(t, newLRUCacher, true, CacheOnGet|CacheOnPut, 1, 3)
 }
 
 func TestPutLargeCache(t *testing.T) {
 	// don't sync, cache, one element
-	testPut(t, false, CacheOnGet|CacheOnPut, 4, 1)
+	testPut(t, newLRUCacher, false, CacheOnGet|CacheOnPut, 4, 1)
 }
 
 func TestPutSyncLargeCache(t *testing.T) {
 	// sync, cache, one element
-	testPut(t, true, CacheOnGet|CacheOnPut, 4, 1)
+	testPut(t, newLRUCacher, true, CacheOnGet|CacheOnPut, 4, 1)
 }
 
 func TestPutMultipleLargeCache(t *testing.T) {
 	// don't sync,cache, three elements
-	testPut(t, false, CacheOnGet|CacheOnPut, 4, 3)
+	testPut(t, newLRUCacher, false, CacheOnGet|CacheOnPut, 4, 3)
 }
 
 func TestPutSyncMultipleLargeCache(t *testing.T) {
 	// sync, cache, three elements
-	testPut(t, true, CacheOnGet|CacheOnPut, 4, 3)
+	testPut(t, newLRUCacher, true, CacheOnGet|CacheOnPut, 4, 3)
+}
+
+func TestPutSmallCacheLFU(t *testing.T) {
+	// don't sync, cache, one element
+	testPut(t, newLFUCacher, false, CacheOnGet|CacheOnPut, 1, 1)
+}
+
+func TestPutSyncSmallCacheLFU(t *testing.T) {
+	// sync, cache, one element
+	testPut(t, newLFUCacher, true, CacheOnGet|CacheOnPut, 1, 1)
+}
+
+func TestPutMultipleSmallCacheLFU(t *testing.T) {
+	// don't sync, cache, three elements
+	testPut(t, newLFUCacher, false, CacheOnGet|CacheOnPut, 1, 3)
+}
+
+func TestPutSyncMultipleSmallCacheLFU(t *testing.T) {
+	// sync, cache, three elements
+	testPut(t, newLFUCacher, true, CacheOnGet|CacheOnPut, 1, 3)
+}
+
+func TestPutLargeCacheLFU(t *testing.T) {
+	// don't sync, cache, one element
+	testPut(t, newLFUCacher, false, CacheOnGet|CacheOnPut, 4, 1)
+}
+
+func TestPutSyncLargeCacheLFU(t *testing.T) {
+	// sync, cache, one element
+	testPut(t, newLFUCacher, true, CacheOnGet|CacheOnPut, 4, 1)
+}
+
+func TestPutMultipleLargeCacheLFU(t *testing.T) {
+	// don't sync,cache, three elements
+	testPut(t, newLFUCacher, false, CacheOnGet|CacheOnPut, 4, 3)
+}
+
+func TestPutSyncMultipleLargeCacheLFU(t *testing.T) {
+	// sync, cache, three elements
+	testPut(t, newLFUCacher, true, CacheOnGet|CacheOnPut, 4, 3)
+}
+
+func TestLFUEviction(t *testing.T) {
+	lfu := NewLFUCache(2)
+	lfu.Add(&TestElems[0])
+	lfu.Add(&TestElems[1])
+
+	// access TestElems[0] again so it is more frequently used than
+	// TestElems[1], which should be evicted first
+	lfu.Get(TestElems[0].ElementID)
+	lfu.Add(&TestElems[2])
+
+	retrieved := lfu.Get(TestElems[1].ElementID)
+	if retrieved != nil {
+		t.Fatal("able to retrieve an element that should have been evicted")
+	}
+
+	retrieved = lfu.Get(TestElems[0].ElementID)
+	if retrieved == nil || retrieved.(*TestElement).Data != TestElems[0].Data {
+		t.Fatal("expected ", TestElems[0].Data, " got ", retrieved)
+	}
+
+	retrieved = lfu.Get(TestElems[2].ElementID)
+	if retrieved == nil || retrieved.(*TestElement).Data != TestElems[2].Data {
+		t.Fatal("expected ", TestElems[2].Data, " got ", retrieved)
+	}
+}
+
+// sizedElement is a TestElement that reports an explicit Size, for
+// exercising SizedLRUCache's byte-charge eviction.
+type sizedElement struct {
+	TestElement
+	ElemSize int64
+}
+
+func (el *sizedElement) Size() int64 {
+	return el.ElemSize
+}
+
+func TestSizedLRUEviction(t *testing.T) {
+	cache := NewSizedLRUCache(10)
+
+	elems := []*sizedElement{
+		{TestElement: TestElement{ElementID: 1, Data: "a"}, ElemSize: 4},
+		{TestElement: TestElement{ElementID: 2, Data: "b"}, ElemSize: 4},
+		{TestElement: TestElement{ElementID: 3, Data: "c"}, ElemSize: 4},
+	}
+
+	for _, el := range elems {
+		cache.Add(el)
+		if cache.Bytes() > cache.ByteCapacity() {
+			t.Fatal("resident bytes exceeded capacity:", cache.Bytes())
+		}
+	}
+
+	// elems[0] (size 4) should have been evicted to make room for
+	// elems[2], since 4+4+4 > 10
+	if cache.Get(elems[0].ElementID) != nil {
+		t.Fatal("able to retrieve an element that should have been evicted")
+	}
+
+	if retrieved := cache.Get(elems[2].ElementID); retrieved == nil ||
+		retrieved.(*sizedElement).Data != elems[2].Data {
+		t.Fatal("expected", elems[2].Data, "got", retrieved)
+	}
+}
+
+func TestSizedLRUDefaultSize(t *testing.T) {
+	// elements that don't implement Sizer are charged as size 1, so a
+	// 2-byte cache behaves like a 2-element LRU
+	cache := NewSizedLRUCache(2)
+	cache.Add(&TestElems[0])
+	cache.Add(&TestElems[1])
+	cache.Add(&TestElems[2])
+
+	if cache.Get(TestElems[0].ElementID) != nil {
+		t.Fatal("able to retrieve an element that should have been evicted")
+	}
+
+	if cache.Bytes() != 2 {
+		t.Fatal("expected 2 resident bytes, got", cache.Bytes())
+	}
+}
+
+func TestSizedLRUOnEvict(t *testing.T) {
+	cache := NewSizedLRUCache(4)
+
+	var evicted Element
+	cache.SetOnEvict(func(el Element) {
+		evicted = el
+	})
+
+	cache.Add(&sizedElement{TestElement: TestElement{ElementID: 1, Data: "a"}, ElemSize: 4})
+	cache.Add(&sizedElement{TestElement: TestElement{ElementID: 2, Data: "b"}, ElemSize: 4})
+
+	if evicted == nil || evicted.ID() != 1 {
+		t.Fatal("expected element 1 to be reported evicted, got", evicted)
+	}
+}
+
+func TestSizedLRUSetByteCapacity(t *testing.T) {
+	cache := NewSizedLRUCache(10)
+	cache.Add(&sizedElement{TestElement: TestElement{ElementID: 1, Data: "a"}, ElemSize: 4})
+	cache.Add(&sizedElement{TestElement: TestElement{ElementID: 2, Data: "b"}, ElemSize: 4})
+
+	cache.SetByteCapacity(4)
+	if cache.Bytes() > cache.ByteCapacity() {
+		t.Fatal("resident bytes exceeded capacity after shrinking:", cache.Bytes())
+	}
+
+	if cache.Get(1) != nil {
+		t.Fatal("able to retrieve an element that should have been evicted")
+	}
+}
+
+func TestSetCacheCapacitySized(t *testing.T) {
+	s, err := New(TestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Remove()
+
+	cache := NewSizedLRUCache(10)
+	s.SetCache(cache, CacheOnPut)
+	s.SetCacheCapacity(4)
+
+	if cache.ByteCapacity() != 4 {
+		t.Fatal("expected byte capacity 4, got", cache.ByteCapacity())
+	}
+}
+
+// slowElement wraps TestElement with an artificial Load delay so the
+// Prefetch benchmarks below are dominated by simulated I/O wait rather
+// than goroutine/channel scheduling overhead, the way real disk or
+// network-backed reads would be.
+type slowElement struct {
+	TestElement
+}
+
+func (el *slowElement) Load(r io.Reader) error {
+	time.Sleep(5 * time.Millisecond)
+	return el.TestElement.Load(r)
+}
+
+func benchmarkGetSetup(b *testing.B, n int) (*Store, []ElementID) {
+	s, err := New(TestPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ids := make([]ElementID, n)
+	for i := 0; i < n; i++ {
+		el := &TestElement{ElementID: ElementID(i + 1), Data: "benchdata"}
+		if err := s.Put(el); err != nil {
+			b.Fatal(err)
+		}
+		ids[i] = el.ElementID
+	}
+	s.Sync()
+
+	return s, ids
+}
+
+// BenchmarkGetSequential loads a batch of elements one Get at a time,
+// each of which opens and reads its own file, with slowElement's
+// artificial delay standing in for real I/O wait.
+func BenchmarkGetSequential(b *testing.B) {
+	s, ids := benchmarkGetSetup(b, 8)
+	defer s.Remove()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			if _, err := s.Get(&slowElement{TestElement: TestElement{ElementID: id}}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkGetPrefetched starts the same batch loading in the
+// background with Prefetch before retrieving it, so the (simulated)
+// disk reads overlap instead of running one after another. A CacheOnGet
+// cache is required for this to pay off: without one, Prefetch's
+// background loads are thrown away the moment single-flight dedup stops
+// covering them, and the following Gets re-read every file from scratch.
+func BenchmarkGetPrefetched(b *testing.B) {
+	s, ids := benchmarkGetSetup(b, 8)
+	defer s.Remove()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		s.SetCache(NewLRUCache(len(ids)), CacheOnGet)
+		b.StartTimer()
+
+		s.Prefetch(func(id ElementID) Element {
+			return &slowElement{TestElement: TestElement{ElementID: id}}
+		}, ids...)
+
+		for _, id := range ids {
+			if _, err := s.Get(&slowElement{TestElement: TestElement{ElementID: id}}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
 }